@@ -0,0 +1,44 @@
+package influxdb
+
+import "sync"
+
+// deltaCache tracks the last absolute value reported per metric name so
+// Counter/Meter/Timer/Histogram points can carry a count.delta field. It is
+// mutex-guarded because a reporter's own ticker-driven send loop and an
+// out-of-band Flush call can race on the same cache from different
+// goroutines.
+type deltaCache struct {
+	mu     sync.Mutex
+	values map[string]int64
+}
+
+func newDeltaCache() *deltaCache {
+	return &deltaCache{values: make(map[string]int64)}
+}
+
+// apply sets fields["count.delta"] to the change in fields[field] since the
+// last call for name, resetting to the current value instead of going
+// negative if it decreased (e.g. the process restarted and the counter
+// reset to zero).
+func (c *deltaCache) apply(name string, fields map[string]interface{}, field string) {
+	raw, ok := fields[field]
+	if !ok {
+		return
+	}
+	current, ok := raw.(int64)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	last := c.values[name]
+	delta := current - last
+	if current < last {
+		delta = current
+	}
+	c.values[name] = current
+
+	fields["count.delta"] = delta
+}
@@ -0,0 +1,100 @@
+package influxdb
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Config customizes how a reporter names measurements and tags the points it
+// writes. The zero value reproduces the package's original behavior: every
+// metric is written as "<name>.<kind>" (e.g. "requests.count") with no
+// namespace prefix.
+type Config struct {
+	// Namespace is prepended to every metric name before it is turned into a
+	// measurement, e.g. "myapp." + "requests" -> "myapp.requests". With the
+	// v2 client (see UseV2) a "." is inserted after a non-empty Namespace, so
+	// "myapp" + "requests" -> "myapp.requests" there.
+	Namespace string
+
+	// Tags are attached to every point an interval reporter writes.
+	// Reporter.Send does not use them; it tags points with the ReporterItem's
+	// Tags instead.
+	Tags map[string]string
+
+	// MeasurementNameFunc, if set, overrides the default "<name>.<kind>"
+	// suffix scheme. It receives the namespaced metric name and its kind
+	// ("count", "gauge", "histogram", "meter", "timer" or "span") and
+	// returns the measurement to write the point under. The kind is also
+	// added as a "kind" tag on the point, so - unlike the default scheme -
+	// a single Grafana query against one measurement can pull counters and
+	// gauges together. This matches the convention used by the go-ethereum
+	// InfluxDB reporter. The v2 client (see UseV2) does not use it.
+	MeasurementNameFunc func(metricName string, kind string) string
+
+	// UseV2 selects the InfluxDB v2 client (token auth against Endpoint,
+	// Bucket and Organization) instead of the v1 client (username/password
+	// against addr/database). It is also inferred from addr's URL scheme:
+	// "http2" or "https2" select v2 the same as setting UseV2 explicitly.
+	UseV2 bool
+
+	// Token, Bucket and Organization configure the v2 client when UseV2 (or
+	// a v2 addr scheme) is in effect. Endpoint is the reporter's addr
+	// argument; Bucket falls back to the reporter's database argument when
+	// empty.
+	Token        string
+	Bucket       string
+	Organization string
+}
+
+// useV2 reports whether addr/cfg select the InfluxDB v2 client.
+func useV2(addr string, cfg Config) bool {
+	if cfg.UseV2 {
+		return true
+	}
+
+	u, err := url.Parse(addr)
+	if err != nil {
+		return false
+	}
+
+	return u.Scheme == "http2" || u.Scheme == "https2"
+}
+
+// v2Endpoint rewrites the "http2"/"https2" scheme convention useV2 sniffs
+// back to the real "http"/"https" scheme the v2 client expects, leaving any
+// other addr (e.g. one supplied via an explicit Config.UseV2) untouched.
+func v2Endpoint(addr string) string {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return addr
+	}
+
+	switch u.Scheme {
+	case "http2":
+		u.Scheme = "http"
+	case "https2":
+		u.Scheme = "https"
+	default:
+		return addr
+	}
+
+	return u.String()
+}
+
+// measurement returns the measurement name and point tags to use for a
+// metric, given its bare name and kind.
+func (cfg Config) measurement(name, kind string, tags map[string]string) (measurement string, pointTags map[string]string) {
+	fqName := cfg.Namespace + name
+
+	if cfg.MeasurementNameFunc == nil {
+		return fmt.Sprintf("%s.%s", fqName, kind), tags
+	}
+
+	pointTags = make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		pointTags[k] = v
+	}
+	pointTags["kind"] = kind
+
+	return cfg.MeasurementNameFunc(fqName, kind), pointTags
+}
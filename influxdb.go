@@ -1,10 +1,11 @@
 package influxdb
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"log"
 	"net/url"
+	"sort"
 	"strconv"
 	"time"
 
@@ -21,17 +22,68 @@ type reporter struct {
 	username string
 	password string
 	tags     map[string]string
+	cfg      Config
 
 	client client.Client
+
+	// cache holds the last absolute Count() seen per metric name, letting
+	// counters, meters, timers and histograms report the interval delta
+	// alongside their normal fields. It is mutex-guarded because Flush can
+	// run send concurrently with the run loop's own ticker-driven send.
+	cache *deltaCache
 }
 
-// InfluxDB starts a InfluxDB reporter which will post the metrics from the given registry at each d interval.
+// InfluxDB starts a InfluxDB reporter which will post the metrics from the
+// given registry at each d interval, blocking forever (it returns only if the
+// InfluxDB client cannot be created). It is a thin wrapper
+// around NewIntervalReporter for callers who don't need to stop the
+// reporter; use NewIntervalReporter directly for graceful shutdown.
 func InfluxDB(r metrics.Registry, d time.Duration, addr, database, username, password string) {
 	WithTags(r, d, addr, database, username, password, nil)
 }
 
 // WithTags starts a InfluxDB reporter which will post the metrics from the given registry at each d interval with the specified tags
 func WithTags(r metrics.Registry, d time.Duration, addr, database, username, password string, tags map[string]string) {
+	WithConfig(r, d, addr, database, username, password, Config{Tags: tags})
+}
+
+// WithConfig starts an InfluxDB reporter like WithTags, but takes a Config so
+// callers can customize the namespace, point tags and measurement naming
+// scheme instead of being limited to the fixed "<name>.<kind>" suffixes.
+// Setting cfg.UseV2, or giving addr an "http2"/"https2" scheme, reports to
+// InfluxDB 2.x (token auth via cfg.Token/Bucket/Organization, with cfg.Bucket
+// falling back to database) instead of the v1 client. On the v2 path,
+// cfg.Namespace is joined to each metric name with a "." (when non-empty),
+// cfg.Tags are attached to every point, and cfg.MeasurementNameFunc is not
+// used: measurements always follow the "<name>.<kind>" scheme. Like InfluxDB
+// and WithTags, it blocks forever unless the v1 client cannot be created, in
+// which case it logs the error and returns; use NewIntervalReporter directly
+// if the reporter needs to be stopped.
+func WithConfig(r metrics.Registry, d time.Duration, addr, database, username, password string, cfg Config) {
+	ir := NewIntervalReporter(r, d, addr, database, username, password, cfg)
+	if ir == nil {
+		return
+	}
+
+	select {}
+}
+
+// NewIntervalReporter starts an InfluxDB reporter which posts the metrics
+// from the given registry at each d interval, returning a handle that can
+// stop the reporter and flush buffered points on shutdown. InfluxDB,
+// WithTags and WithConfig block forever instead; use this constructor
+// directly when the caller needs graceful shutdown. cfg.UseV2, or an
+// "http2"/"https2" addr scheme, routes to the InfluxDB v2 client instead of
+// the v1 client; see WithConfig for the parameter mapping.
+func NewIntervalReporter(r metrics.Registry, d time.Duration, addr, database, username, password string, cfg Config) *IntervalReporter {
+	if useV2(addr, cfg) {
+		bucket := cfg.Bucket
+		if bucket == "" {
+			bucket = database
+		}
+		return NewIntervalReporterV2(r, d, v2Endpoint(addr), cfg.Token, bucket, cfg.Organization, cfg.Namespace, cfg.Tags)
+	}
+
 	rep := &reporter{
 		reg:      r,
 		interval: d,
@@ -39,14 +91,55 @@ func WithTags(r metrics.Registry, d time.Duration, addr, database, username, pas
 		database: database,
 		username: username,
 		password: password,
-		tags:     tags,
+		tags:     cfg.Tags,
+		cfg:      cfg,
+		cache:    newDeltaCache(),
 	}
 	if err := rep.makeClient(); err != nil {
 		log.Printf("unable to make InfluxDB client. err=%v", err)
-		return
+		return nil
 	}
 
-	rep.run()
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		rep.run(done)
+		close(stopped)
+	}()
+
+	return &IntervalReporter{flush: rep.send, done: done, stopped: stopped}
+}
+
+// IntervalReporter is a running interval-based reporter handle, returned by
+// NewIntervalReporter and NewWriterReporter, that can be stopped and
+// out-of-band flushed regardless of which sink backs it.
+type IntervalReporter struct {
+	flush   func() error
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// Stop ends the reporting loop, flushing any buffered points with one final
+// send and closing the underlying InfluxDB client before returning. The
+// io.Writer given to NewWriterReporter is not closed.
+func (ir *IntervalReporter) Stop() {
+	close(ir.done)
+	<-ir.stopped
+}
+
+// Flush sends the current metrics snapshot immediately, independent of the
+// regular interval. It returns ctx.Err() if ctx is done before the send
+// completes.
+func (ir *IntervalReporter) Flush(ctx context.Context) error {
+	errCh := make(chan error, 1)
+	go func() { errCh <- ir.flush() }()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (r *reporter) makeClient() (err error) {
@@ -75,17 +168,21 @@ func (r *reporter) makeClient() (err error) {
 	return err
 }
 
-func (r *reporter) run() {
-	intervalTicker := time.Tick(r.interval)
-	pingTicker := time.Tick(time.Second * 5)
+// run drives the reporting loop until done is closed, at which point it
+// sends one final flush and closes the InfluxDB client before returning.
+func (r *reporter) run(done <-chan struct{}) {
+	intervalTicker := time.NewTicker(r.interval)
+	defer intervalTicker.Stop()
+	pingTicker := time.NewTicker(time.Second * 5)
+	defer pingTicker.Stop()
 
 	for {
 		select {
-		case <-intervalTicker:
+		case <-intervalTicker.C:
 			if err := r.send(); err != nil {
 				log.Printf("unable to send metrics to InfluxDB. err=%v", err)
 			}
-		case <-pingTicker:
+		case <-pingTicker.C:
 			_, _, err := r.client.Ping(1 * time.Second)
 			if err != nil {
 				log.Printf("got error while sending a ping to InfluxDB, trying to recreate client. err=%v", err)
@@ -94,6 +191,14 @@ func (r *reporter) run() {
 					log.Printf("unable to make InfluxDB client. err=%v", err)
 				}
 			}
+		case <-done:
+			if err := r.send(); err != nil {
+				log.Printf("unable to flush metrics to InfluxDB on shutdown. err=%v", err)
+			}
+			if err := r.client.Close(); err != nil {
+				log.Printf("unable to close InfluxDB client. err=%v", err)
+			}
+			return
 		}
 	}
 }
@@ -106,88 +211,181 @@ func (r *reporter) send() error {
 		return err
 	}
 
-	r.reg.Each(func(name string, i interface{}) {
-		now := time.Now()
+	for _, p := range collectPoints(r.reg, r.cfg, r.tags, r.cache, time.Now()) {
+		pt, _ := client.NewPoint(p.measurement, p.tags, p.fields, p.time)
+		bp.AddPoint(pt)
+	}
 
-		switch metric := i.(type) {
+	return r.client.Write(bp)
+}
+
+// addCountDelta adds a "count.delta" field holding the change in fields[field]
+// since the last call for name, so dashboards can graph throughput directly
+// instead of relying on InfluxDB's non_negative_derivative over an
+// ever-growing absolute count. If the current value is lower than the
+// cached one (e.g. the process restarted and the counter reset to zero),
+// the delta is reported as the current value rather than going negative.
+func (r *reporter) addCountDelta(name string, fields map[string]interface{}, field string) {
+	r.cache.apply(name, fields, field)
+}
+
+// point is the sink-agnostic result of converting one metric into something
+// writable to InfluxDB: a measurement name, its tags, fields and timestamp.
+type point struct {
+	measurement string
+	tags        map[string]string
+	fields      map[string]interface{}
+	time        time.Time
+}
+
+// collectPoints walks reg and converts every metric into a point, applying
+// cfg's namespace/naming scheme and cache's delta bookkeeping along the way.
+// It is the shared point-construction step behind every sink in this package
+// (the v1 HTTP/UDP client, the v2 client, and the io.Writer line-protocol
+// sink), so they stay in lockstep with each other.
+func collectPoints(reg metrics.Registry, cfg Config, tags map[string]string, cache *deltaCache, now time.Time) []point {
+	var points []point
+
+	reg.Each(func(name string, i interface{}) {
+		kind, fields := metricFields(name, i)
+		if fields == nil {
+			return
+		}
+
+		switch i.(type) {
 		case metrics.Counter:
-			ms := metric.Snapshot()
-			fields := map[string]interface{}{
-				"value": ms.Count(),
-			}
-			pt, _ := client.NewPoint(fmt.Sprintf("%s.count", name), r.tags, fields, now)
-			bp.AddPoint(pt)
-		case metrics.Gauge:
-			ms := metric.Snapshot()
-			fields := map[string]interface{}{
-				"value": ms.Value(),
-			}
-			pt, _ := client.NewPoint(fmt.Sprintf("%s.gauge", name), r.tags, fields, now)
-			bp.AddPoint(pt)
-		case metrics.GaugeFloat64:
-			ms := metric.Snapshot()
-			fields := map[string]interface{}{
-				"value": ms.Value(),
-			}
-			pt, _ := client.NewPoint(fmt.Sprintf("%s.gauge", name), r.tags, fields, now)
-			bp.AddPoint(pt)
-		case metrics.Histogram:
-			ms := metric.Snapshot()
-			ps := ms.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999})
-			fields := map[string]interface{}{
-				"count":    ms.Count(),
-				"max":      ms.Max(),
-				"mean":     ms.Mean(),
-				"min":      ms.Min(),
-				"stddev":   ms.StdDev(),
-				"variance": ms.Variance(),
-				"p50":      ps[0],
-				"p75":      ps[1],
-				"p95":      ps[2],
-				"p99":      ps[3],
-				"p999":     ps[4],
-				"p9999":    ps[5],
-			}
-			pt, _ := client.NewPoint(fmt.Sprintf("%s.histogram", name), r.tags, fields, now)
-			bp.AddPoint(pt)
-		case metrics.Meter:
-			ms := metric.Snapshot()
-			fields := map[string]interface{}{
-				"count": ms.Count(),
-				"m1":    ms.Rate1(),
-				"m5":    ms.Rate5(),
-				"m15":   ms.Rate15(),
-				"mean":  ms.RateMean(),
-			}
-			pt, _ := client.NewPoint(fmt.Sprintf("%s.meter", name), r.tags, fields, now)
-			bp.AddPoint(pt)
-		case metrics.Timer:
-			ms := metric.Snapshot()
-			ps := ms.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999})
-			fields := map[string]interface{}{
-				"count":    ms.Count(),
-				"max":      ms.Max(),
-				"mean":     ms.Mean(),
-				"min":      ms.Min(),
-				"stddev":   ms.StdDev(),
-				"variance": ms.Variance(),
-				"p50":      ps[0],
-				"p75":      ps[1],
-				"p95":      ps[2],
-				"p99":      ps[3],
-				"p999":     ps[4],
-				"p9999":    ps[5],
-				"m1":       ms.Rate1(),
-				"m5":       ms.Rate5(),
-				"m15":      ms.Rate15(),
-				"meanrate": ms.RateMean(),
-			}
-			pt, _ := client.NewPoint(fmt.Sprintf("%s.timer", name), r.tags, fields, now)
-			bp.AddPoint(pt)
+			cache.apply(name, fields, "value")
+		case metrics.Meter, metrics.Timer, metrics.Histogram:
+			cache.apply(name, fields, "count")
 		}
+
+		measurement, pointTags := cfg.measurement(name, kind, tags)
+		points = append(points, point{measurement: measurement, tags: pointTags, fields: fields, time: now})
 	})
 
-	return r.client.Write(bp)
+	return points
+}
+
+// metricFields converts a single go-metrics metric into its measurement kind
+// ("count", "gauge", "histogram", "meter", "timer" or "span") and the field
+// set this package writes to InfluxDB for it. It is shared by every reporter
+// implementation (v1, v2, writer) so they stay in lockstep; each caller is
+// responsible for turning (name, kind) into an actual measurement name.
+func metricFields(name string, i interface{}) (kind string, fields map[string]interface{}) {
+	switch metric := i.(type) {
+	case metrics.Counter:
+		ms := metric.Snapshot()
+		return "count", map[string]interface{}{
+			"value": ms.Count(),
+		}
+	case metrics.Gauge:
+		ms := metric.Snapshot()
+		return "gauge", map[string]interface{}{
+			"value": ms.Value(),
+		}
+	case metrics.GaugeFloat64:
+		ms := metric.Snapshot()
+		return "gauge", map[string]interface{}{
+			"value": ms.Value(),
+		}
+	case metrics.Histogram:
+		ms := metric.Snapshot()
+		ps := ms.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999})
+		return "histogram", map[string]interface{}{
+			"count":    ms.Count(),
+			"max":      ms.Max(),
+			"mean":     ms.Mean(),
+			"min":      ms.Min(),
+			"stddev":   ms.StdDev(),
+			"variance": ms.Variance(),
+			"p50":      ps[0],
+			"p75":      ps[1],
+			"p95":      ps[2],
+			"p99":      ps[3],
+			"p999":     ps[4],
+			"p9999":    ps[5],
+		}
+	case metrics.Meter:
+		ms := metric.Snapshot()
+		return "meter", map[string]interface{}{
+			"count": ms.Count(),
+			"m1":    ms.Rate1(),
+			"m5":    ms.Rate5(),
+			"m15":   ms.Rate15(),
+			"mean":  ms.RateMean(),
+		}
+	case metrics.Timer:
+		ms := metric.Snapshot()
+		ps := ms.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999})
+		return "timer", map[string]interface{}{
+			"count":    ms.Count(),
+			"max":      ms.Max(),
+			"mean":     ms.Mean(),
+			"min":      ms.Min(),
+			"stddev":   ms.StdDev(),
+			"variance": ms.Variance(),
+			"p50":      ps[0],
+			"p75":      ps[1],
+			"p95":      ps[2],
+			"p99":      ps[3],
+			"p999":     ps[4],
+			"p9999":    ps[5],
+			"m1":       ms.Rate1(),
+			"m5":       ms.Rate5(),
+			"m15":      ms.Rate15(),
+			"meanrate": ms.RateMean(),
+		}
+	case ResettingTimer:
+		ss := metric.Snapshot()
+		values := ss.Values()
+		sort.Sort(int64Slice(values))
+
+		var min, max int64
+		if len(values) > 0 {
+			min, max = values[0], values[len(values)-1]
+		}
+
+		return "span", map[string]interface{}{
+			"count": int64(len(values)),
+			"mean":  ss.Mean(),
+			"min":   min,
+			"max":   max,
+			"p50":   percentile(values, 0.5),
+			"p95":   percentile(values, 0.95),
+			"p99":   percentile(values, 0.99),
+		}
+	}
+
+	return "", nil
+}
+
+// int64Slice implements sort.Interface so ResettingTimer snapshots can be
+// sorted in place before their percentiles are computed.
+type int64Slice []int64
+
+func (s int64Slice) Len() int           { return len(s) }
+func (s int64Slice) Less(i, j int) bool { return s[i] < s[j] }
+func (s int64Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// percentile returns the p-th percentile (0 <= p <= 1) of a sorted slice of
+// values, linearly interpolating between the two closest ranks.
+func percentile(sorted []int64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	if len(sorted) == 1 {
+		return float64(sorted[0])
+	}
+
+	rank := p * float64(len(sorted)-1)
+	lo := int(rank)
+	hi := lo + 1
+	if hi >= len(sorted) {
+		return float64(sorted[lo])
+	}
+
+	frac := rank - float64(lo)
+	return float64(sorted[lo]) + frac*float64(sorted[hi]-sorted[lo])
 }
 
 //ReporterItem 发送一条数据的内容
@@ -200,6 +398,10 @@ type ReporterItem struct {
 type Reporter struct {
 	database string
 	client   client.Client
+
+	// Config customizes the measurement naming scheme Send uses; its zero
+	// value preserves the original "<measureName>.gauge" behavior.
+	Config Config
 }
 
 //NewReporter 发送批量的metrics数据(当前为gauge)到influxDB, 非定时发送
@@ -250,7 +452,8 @@ func (r *Reporter) Send(item *ReporterItem, measureName string) error {
 				"value":      ms.Value(),
 				"start_time": st,
 			}
-			pt, _ := client.NewPoint(fmt.Sprintf("%s.gauge", measureName), item.Tags, fields, now)
+			measurement, tags := r.Config.measurement(measureName, "gauge", item.Tags)
+			pt, _ := client.NewPoint(measurement, tags, fields, now)
 			bp.AddPoint(pt)
 		}
 	})
@@ -0,0 +1,34 @@
+package influxdb
+
+import "testing"
+
+func TestReporterAddCountDeltaMonotonic(t *testing.T) {
+	r := &reporter{cache: newDeltaCache()}
+
+	fields := map[string]interface{}{"value": int64(10)}
+	r.addCountDelta("requests", fields, "value")
+	if got := fields["count.delta"]; got != int64(10) {
+		t.Fatalf("first delta = %v, want 10", got)
+	}
+
+	fields = map[string]interface{}{"value": int64(25)}
+	r.addCountDelta("requests", fields, "value")
+	if got := fields["count.delta"]; got != int64(15) {
+		t.Fatalf("second delta = %v, want 15", got)
+	}
+}
+
+func TestReporterAddCountDeltaReset(t *testing.T) {
+	cache := newDeltaCache()
+	cache.values["requests"] = 100
+	r := &reporter{cache: cache}
+
+	fields := map[string]interface{}{"value": int64(5)}
+	r.addCountDelta("requests", fields, "value")
+	if got := fields["count.delta"]; got != int64(5) {
+		t.Fatalf("delta after reset = %v, want 5 (current value, not negative)", got)
+	}
+	if r.cache.values["requests"] != 5 {
+		t.Fatalf("cache after reset = %v, want 5", r.cache.values["requests"])
+	}
+}
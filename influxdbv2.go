@@ -0,0 +1,131 @@
+package influxdb
+
+import (
+	"log"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api"
+	"github.com/rcrowley/go-metrics"
+)
+
+// reporterV2 posts metrics to InfluxDB 2.x over its HTTP API using token
+// auth and the async write path.
+type reporterV2 struct {
+	reg      metrics.Registry
+	interval time.Duration
+
+	endpoint     string
+	token        string
+	bucket       string
+	organization string
+	namespace    string
+	tags         map[string]string
+
+	client   influxdb2.Client
+	writeAPI api.WriteAPI
+
+	// cache holds the last absolute value seen per metric name so counters,
+	// meters, timers and histograms can be reported as deltas since the
+	// previous flush. It is
+	// mutex-guarded because Flush can run send concurrently with the run
+	// loop's own ticker-driven send.
+	cache *deltaCache
+}
+
+// InfluxDBV2 starts an InfluxDB 2.x reporter which will post the metrics from
+// the given registry at each d interval to endpoint/bucket using token auth,
+// blocking forever. It is a thin wrapper around NewIntervalReporter for
+// callers who don't need to stop the reporter.
+func InfluxDBV2(r metrics.Registry, d time.Duration, endpoint, token, bucket, organization, namespace string) {
+	InfluxDBV2WithTags(r, d, endpoint, token, bucket, organization, namespace, nil)
+}
+
+// InfluxDBV2WithTags starts an InfluxDB 2.x reporter like InfluxDBV2 but
+// attaches the given tags to every point it writes. Like InfluxDBV2, it
+// blocks forever; use NewIntervalReporter (with Config.UseV2 set, or an
+// "http2"/"https2" addr scheme) directly for graceful shutdown.
+func InfluxDBV2WithTags(r metrics.Registry, d time.Duration, endpoint, token, bucket, organization, namespace string, tags map[string]string) {
+	ir := NewIntervalReporterV2(r, d, endpoint, token, bucket, organization, namespace, tags)
+	if ir == nil {
+		return
+	}
+
+	select {}
+}
+
+// NewIntervalReporterV2 starts an InfluxDB v2 reporter like InfluxDBV2WithTags,
+// returning a handle that can stop the reporter and flush buffered points on
+// shutdown instead of blocking forever - the same IntervalReporter plumbing
+// NewIntervalReporter and NewWriterReporter use for the v1 and writer sinks.
+func NewIntervalReporterV2(r metrics.Registry, d time.Duration, endpoint, token, bucket, organization, namespace string, tags map[string]string) *IntervalReporter {
+	rep := &reporterV2{
+		reg:          r,
+		interval:     d,
+		endpoint:     endpoint,
+		token:        token,
+		bucket:       bucket,
+		organization: organization,
+		namespace:    namespace,
+		tags:         tags,
+		cache:        newDeltaCache(),
+	}
+
+	rep.client = influxdb2.NewClient(endpoint, token)
+	rep.writeAPI = rep.client.WriteAPI(organization, bucket)
+
+	go rep.watchErrors()
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		rep.run(done)
+		close(stopped)
+	}()
+
+	return &IntervalReporter{flush: rep.send, done: done, stopped: stopped}
+}
+
+// watchErrors drains the write API's error channel so a full buffer never
+// blocks writes, logging anything the async writer couldn't deliver.
+func (r *reporterV2) watchErrors() {
+	for err := range r.writeAPI.Errors() {
+		log.Printf("unable to write point to InfluxDB v2. err=%v", err)
+	}
+}
+
+// run drives the reporting loop until done is closed, at which point it
+// sends one final flush and closes the InfluxDB client before returning.
+func (r *reporterV2) run(done <-chan struct{}) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.send(); err != nil {
+				log.Printf("unable to send metrics to InfluxDB v2. err=%v", err)
+			}
+		case <-done:
+			if err := r.send(); err != nil {
+				log.Printf("unable to flush metrics to InfluxDB v2 on shutdown. err=%v", err)
+			}
+			r.client.Close()
+			return
+		}
+	}
+}
+
+func (r *reporterV2) send() error {
+	cfg := Config{}
+	if r.namespace != "" {
+		cfg.Namespace = r.namespace + "."
+	}
+
+	for _, p := range collectPoints(r.reg, cfg, r.tags, r.cache, time.Now()) {
+		r.writeAPI.WritePoint(influxdb2.NewPoint(p.measurement, p.tags, p.fields, p.time))
+	}
+	r.writeAPI.Flush()
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package influxdb
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// resettingTimerSnapshot is a read-only copy of a ResettingTimer's buffered
+// values at the moment Snapshot was called.
+type resettingTimerSnapshot struct {
+	values []int64
+}
+
+// Values returns the raw durations recorded since the timer was last reset.
+func (s *resettingTimerSnapshot) Values() []int64 {
+	values := make([]int64, len(s.values))
+	copy(values, s.values)
+	return values
+}
+
+// Mean returns the arithmetic mean of the recorded values.
+func (s *resettingTimerSnapshot) Mean() float64 {
+	if len(s.values) == 0 {
+		return 0
+	}
+
+	var sum int64
+	for _, v := range s.values {
+		sum += v
+	}
+	return float64(sum) / float64(len(s.values))
+}
+
+// ResettingTimer buffers durations between flushes without the EWMA
+// smoothing metrics.Timer applies, so short-lived latency bursts show up in
+// the percentiles for the interval they happened in instead of being
+// dampened across several intervals. A Snapshot clears the buffer.
+//
+// Unlike metrics.Timer it has no moving average rates; it only tracks the
+// raw values recorded since the last Snapshot.
+type ResettingTimer interface {
+	Update(d int64)
+	Values() []int64
+	Snapshot() ResettingTimerSnapshot
+	Mean() float64
+}
+
+// ResettingTimerSnapshot is a read-only copy of a ResettingTimer.
+type ResettingTimerSnapshot interface {
+	Values() []int64
+	Mean() float64
+}
+
+// NewResettingTimer constructs a new StandardResettingTimer with no bound on
+// the number of values it buffers between flushes.
+func NewResettingTimer() ResettingTimer {
+	return &StandardResettingTimer{}
+}
+
+// NewResettingTimerWithCap constructs a new StandardResettingTimer whose
+// buffer is bounded to cap values. Once the buffer is full, new values
+// replace a uniformly random existing one (reservoir sampling) so the
+// snapshot remains a representative sample instead of only the most recent
+// readings.
+func NewResettingTimerWithCap(cap int) ResettingTimer {
+	return &StandardResettingTimer{cap: cap}
+}
+
+// StandardResettingTimer is the standard implementation of a ResettingTimer.
+// It is safe for concurrent use.
+type StandardResettingTimer struct {
+	mu     sync.Mutex
+	values []int64
+	count  int64
+	cap    int
+}
+
+// Update records a duration observed since the last reset.
+func (t *StandardResettingTimer) Update(d int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.count++
+	if t.cap <= 0 || len(t.values) < t.cap {
+		t.values = append(t.values, d)
+		return
+	}
+
+	// Reservoir sampling: replace a uniformly random existing value so the
+	// buffer stays bounded while remaining representative of every update.
+	if idx := rand.Intn(int(t.count)); idx < len(t.values) {
+		t.values[idx] = d
+	}
+}
+
+// Values returns the durations recorded since the last Snapshot.
+func (t *StandardResettingTimer) Values() []int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	values := make([]int64, len(t.values))
+	copy(values, t.values)
+	return values
+}
+
+// Mean returns the arithmetic mean of the values recorded since the last
+// Snapshot.
+func (t *StandardResettingTimer) Mean() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.values) == 0 {
+		return 0
+	}
+
+	var sum int64
+	for _, v := range t.values {
+		sum += v
+	}
+	return float64(sum) / float64(len(t.values))
+}
+
+// Snapshot copies the values recorded since the last Snapshot and resets the
+// timer's buffer.
+func (t *StandardResettingTimer) Snapshot() ResettingTimerSnapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	values := t.values
+	t.values = nil
+	t.count = 0
+	return &resettingTimerSnapshot{values: values}
+}
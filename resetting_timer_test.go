@@ -0,0 +1,100 @@
+package influxdb
+
+import "testing"
+
+func TestStandardResettingTimerReservoirSampling(t *testing.T) {
+	timer := NewResettingTimerWithCap(10)
+	for i := int64(0); i < 1000; i++ {
+		timer.Update(i)
+	}
+
+	values := timer.Values()
+	if len(values) != 10 {
+		t.Fatalf("len(Values()) = %d, want 10 (bounded by cap)", len(values))
+	}
+
+	for _, v := range values {
+		if v < 0 || v >= 1000 {
+			t.Fatalf("sampled value %d out of the updated range [0, 1000)", v)
+		}
+	}
+
+	ss := timer.Snapshot()
+	if got := len(ss.Values()); got != 10 {
+		t.Fatalf("len(Snapshot().Values()) = %d, want 10", got)
+	}
+	if got := len(timer.Values()); got != 0 {
+		t.Fatalf("len(Values()) after Snapshot = %d, want 0 (buffer reset)", got)
+	}
+}
+
+func TestResettingTimerSnapshotValuesIsACopy(t *testing.T) {
+	timer := NewResettingTimer()
+	timer.Update(1)
+	timer.Update(2)
+
+	ss := timer.Snapshot()
+	values := ss.Values()
+	values[0] = 999
+
+	if got := ss.Values()[0]; got != 1 {
+		t.Fatalf("mutating the slice returned by Values() changed the snapshot; got %d, want 1", got)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := int64Slice{10, 20, 30, 40, 50}
+
+	cases := []struct {
+		p    float64
+		want float64
+	}{
+		{0, 10},
+		{0.5, 30},
+		{1, 50},
+		{0.25, 20},
+		{0.75, 40},
+	}
+	for _, c := range cases {
+		if got := percentile(values, c.p); got != c.want {
+			t.Errorf("percentile(%v, %v) = %v, want %v", []int64(values), c.p, got, c.want)
+		}
+	}
+}
+
+func TestPercentileEmptyAndSingleton(t *testing.T) {
+	if got := percentile(nil, 0.5); got != 0 {
+		t.Errorf("percentile(nil, 0.5) = %v, want 0", got)
+	}
+	if got := percentile(int64Slice{42}, 0.99); got != 42 {
+		t.Errorf("percentile of a single value = %v, want 42", got)
+	}
+}
+
+func TestMetricFieldsResettingTimerSpan(t *testing.T) {
+	timer := NewResettingTimer()
+	for _, d := range []int64{10, 20, 30, 40, 50} {
+		timer.Update(d)
+	}
+
+	kind, fields := metricFields("latency", timer)
+	if kind != "span" {
+		t.Fatalf("kind = %q, want %q", kind, "span")
+	}
+
+	if got := fields["count"]; got != int64(5) {
+		t.Errorf("count = %v, want 5", got)
+	}
+	if got := fields["min"]; got != int64(10) {
+		t.Errorf("min = %v, want 10", got)
+	}
+	if got := fields["max"]; got != int64(50) {
+		t.Errorf("max = %v, want 50", got)
+	}
+	if got := fields["mean"]; got != 30.0 {
+		t.Errorf("mean = %v, want 30", got)
+	}
+	if got := fields["p50"]; got != 30.0 {
+		t.Errorf("p50 = %v, want 30", got)
+	}
+}
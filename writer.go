@@ -0,0 +1,145 @@
+package influxdb
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// writerReporter serializes metrics to InfluxDB line protocol and writes
+// them to an io.Writer instead of opening a network client, so callers can
+// pipe metrics into Telegraf via a Unix socket, tail them in logs during
+// development, or ship them to anything else that accepts line protocol.
+type writerReporter struct {
+	reg      metrics.Registry
+	interval time.Duration
+	w        io.Writer
+	tags     map[string]string
+	cfg      Config
+
+	// cache holds the last absolute value seen per metric name so counters,
+	// meters, timers and histograms can be reported as deltas. It is
+	// mutex-guarded because Flush can run send concurrently with the run
+	// loop's own ticker-driven send.
+	cache *deltaCache
+}
+
+// NewWriterReporter starts a reporter which writes the metrics from the
+// given registry at each d interval, as InfluxDB line protocol, to w. It
+// returns a handle that can stop the reporter and flush it on shutdown, the
+// same as NewIntervalReporter.
+func NewWriterReporter(r metrics.Registry, d time.Duration, w io.Writer, tags map[string]string) *IntervalReporter {
+	rep := &writerReporter{
+		reg:      r,
+		interval: d,
+		w:        w,
+		tags:     tags,
+		cfg:      Config{Tags: tags},
+		cache:    newDeltaCache(),
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		rep.run(done)
+		close(stopped)
+	}()
+
+	return &IntervalReporter{flush: rep.send, done: done, stopped: stopped}
+}
+
+func (r *writerReporter) run(done <-chan struct{}) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.send(); err != nil {
+				log.Printf("unable to write metrics line protocol. err=%v", err)
+			}
+		case <-done:
+			if err := r.send(); err != nil {
+				log.Printf("unable to flush metrics line protocol on shutdown. err=%v", err)
+			}
+			return
+		}
+	}
+}
+
+func (r *writerReporter) send() error {
+	var b strings.Builder
+	for _, p := range collectPoints(r.reg, r.cfg, r.tags, r.cache, time.Now()) {
+		writeLineProtocol(&b, p)
+	}
+
+	_, err := io.WriteString(r.w, b.String())
+	return err
+}
+
+// writeLineProtocol appends p to b in InfluxDB line protocol:
+// measurement,tag=val,tag=val field=val,field=val timestamp
+func writeLineProtocol(b *strings.Builder, p point) {
+	b.WriteString(escapeLineProtocol(p.measurement))
+
+	tagKeys := make([]string, 0, len(p.tags))
+	for k := range p.tags {
+		tagKeys = append(tagKeys, k)
+	}
+	sort.Strings(tagKeys)
+	for _, k := range tagKeys {
+		b.WriteByte(',')
+		b.WriteString(escapeLineProtocol(k))
+		b.WriteByte('=')
+		b.WriteString(escapeLineProtocol(p.tags[k]))
+	}
+
+	fieldKeys := make([]string, 0, len(p.fields))
+	for k := range p.fields {
+		fieldKeys = append(fieldKeys, k)
+	}
+	sort.Strings(fieldKeys)
+
+	b.WriteByte(' ')
+	for i, k := range fieldKeys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(escapeLineProtocol(k))
+		b.WriteByte('=')
+		b.WriteString(formatLineProtocolValue(p.fields[k]))
+	}
+
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(p.time.UnixNano(), 10))
+	b.WriteByte('\n')
+}
+
+// escapeLineProtocol escapes the characters line protocol treats specially
+// in measurement, tag key and tag value positions.
+func escapeLineProtocol(s string) string {
+	r := strings.NewReplacer(",", "\\,", " ", "\\ ", "=", "\\=")
+	return r.Replace(s)
+}
+
+// formatLineProtocolValue renders a field value in line protocol syntax,
+// tagging integers with the trailing "i" line protocol requires to tell
+// them apart from floats.
+func formatLineProtocolValue(v interface{}) string {
+	switch n := v.(type) {
+	case int64:
+		return strconv.FormatInt(n, 10) + "i"
+	case int:
+		return strconv.Itoa(n) + "i"
+	case float64:
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", n)
+	}
+}